@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSingularityVolumeJSONRoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		volume SingularityVolume
+		want   string
+	}{
+		{
+			name:   "full",
+			volume: SingularityVolume{HostPath: "/data", ContainerPath: "/mnt/data", Mode: "RW"},
+			want:   `{"hostPath":"/data","containerPath":"/mnt/data","mode":"RW"}`,
+		},
+		{
+			name:   "empty",
+			volume: SingularityVolume{},
+			want:   `{}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b, err := json.Marshal(c.volume)
+			assert.NoError(t, err)
+			assert.JSONEq(t, c.want, string(b))
+
+			var got SingularityVolume
+			assert.NoError(t, json.Unmarshal(b, &got))
+			assert.Equal(t, c.volume, got)
+		})
+	}
+}
+
+func TestSingularityPortMappingJSONRoundTrip(t *testing.T) {
+	mapping := SingularityPortMapping{
+		HostPort:          8080,
+		ContainerPort:     80,
+		ContainerPortType: PortMappingLiteral,
+		Protocol:          "tcp",
+		HostPortType:      PortMappingFromOffset,
+	}
+
+	b, err := json.Marshal(mapping)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"hostPort": 8080,
+		"containerPort": 80,
+		"containerPortType": "LITERAL",
+		"protocol": "tcp",
+		"hostPortType": "FROM_OFFSET"
+	}`, string(b))
+
+	var got SingularityPortMapping
+	assert.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, mapping, got)
+}
+
+func TestDeployPayloadIncludesHealthcheckWhenSet(t *testing.T) {
+	cfg := SingularityConfig{
+		RequestID: "my-request",
+		DeployID:  "my-deploy",
+		Healthcheck: SingularityHealthcheckOptions{
+			URI:             "/health",
+			PortIndex:       0,
+			IntervalSeconds: 5,
+			MaxRetries:      3,
+		},
+	}
+
+	b, err := json.Marshal(cfg.DeployPayload())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"deploy": {
+			"requestId": "my-request",
+			"id": "my-deploy",
+			"resources": {},
+			"healthcheck": {
+				"uri": "/health",
+				"intervalSeconds": 5,
+				"maxRetries": 3
+			}
+		}
+	}`, string(b))
+}
+
+func TestDeployPayloadOmitsHealthcheckWhenUnset(t *testing.T) {
+	cfg := SingularityConfig{RequestID: "my-request", DeployID: "my-deploy"}
+
+	payload := cfg.DeployPayload()
+	assert.Nil(t, payload.Deploy.Healthcheck)
+}
+
+func TestSingularityContainerInfoJSONRoundTrip(t *testing.T) {
+	info := SingularityContainerInfo{
+		Type: "DOCKER",
+		Docker: SingularityDockerInfo{
+			Image:   "example/app:latest",
+			Network: "BRIDGE",
+			PortMappings: []SingularityPortMapping{
+				{HostPort: 0, ContainerPort: 8080, ContainerPortType: PortMappingFromOffset},
+			},
+		},
+		Volumes: []SingularityVolume{
+			{HostPath: "/etc/passwd", ContainerPath: "/etc/passwd", Mode: "RO"},
+		},
+	}
+
+	b, err := json.Marshal(info)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "DOCKER",
+		"docker": {
+			"privileged": false,
+			"network": "BRIDGE",
+			"image": "example/app:latest",
+			"portMappings": [
+				{"hostPort": 0, "containerPort": 8080, "containerPortType": "FROM_OFFSET"}
+			]
+		},
+		"volumes": [
+			{"hostPath": "/etc/passwd", "containerPath": "/etc/passwd", "mode": "RO"}
+		]
+	}`, string(b))
+
+	var got SingularityContainerInfo
+	assert.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, info, got)
+}
+
+func TestGeneratePasswdGroupFilesContents(t *testing.T) {
+	mounts := []SingularityMount{
+		{Username: "app", UID: 1000, Group: "app", GID: 1000},
+		{Username: "nobody", UID: 65534, GID: 65534},
+	}
+
+	assert.Equal(t, "app:x:1000:1000::/:/sbin/nologin\nnobody:x:65534:65534::/:/sbin/nologin\n", passwdFileContents(mounts))
+	assert.Equal(t, "app:x:1000:\nnobody:x:65534:\n", groupFileContents(mounts))
+}
+
+func TestGeneratePasswdGroupFilesWritesVolumes(t *testing.T) {
+	dir := t.TempDir()
+
+	volumes, err := generatePasswdGroupFiles([]SingularityMount{{Username: "app", UID: 1000, GID: 1000}}, dir)
+	assert.NoError(t, err)
+	assert.Len(t, volumes, 2)
+	assert.Equal(t, "/etc/passwd", volumes[0].ContainerPath)
+	assert.Equal(t, "RO", volumes[0].Mode)
+	assert.Equal(t, "/etc/group", volumes[1].ContainerPath)
+	assert.Equal(t, "RO", volumes[1].Mode)
+}
+
+func TestGeneratePasswdGroupFilesNoMounts(t *testing.T) {
+	volumes, err := generatePasswdGroupFiles(nil, t.TempDir())
+	assert.NoError(t, err)
+	assert.Nil(t, volumes)
+}
+
+func TestValidateMountsRejectsMissingContainerInfoType(t *testing.T) {
+	cfg := SingularityConfig{
+		Mounts: []SingularityMount{{Username: "app", UID: 1000, GID: 1000}},
+	}
+
+	err := validateMounts(cfg)
+	assert.Error(t, err)
+}
+
+func TestValidateMountsAcceptsContainerInfoType(t *testing.T) {
+	cfg := SingularityConfig{
+		Mounts:        []SingularityMount{{Username: "app", UID: 1000, GID: 1000}},
+		ContainerInfo: SingularityContainerInfo{Type: "DOCKER"},
+	}
+
+	assert.NoError(t, validateMounts(cfg))
+}
+
+func TestValidateMountsAllowsContainerInfoWithoutMounts(t *testing.T) {
+	assert.NoError(t, validateMounts(SingularityConfig{ContainerInfo: SingularityContainerInfo{Type: "DOCKER"}}))
+}
+
+func TestMountsVolumesSurviveIntoDeployPayloadWhenContainerInfoSet(t *testing.T) {
+	cfg := SingularityConfig{
+		RequestID:     "my-request",
+		DeployID:      "my-deploy",
+		ContainerInfo: SingularityContainerInfo{Type: "DOCKER"},
+	}
+
+	mountVolumes, err := generatePasswdGroupFiles([]SingularityMount{{Username: "app", UID: 1000, GID: 1000}}, t.TempDir())
+	assert.NoError(t, err)
+	cfg.ContainerInfo.Volumes = append(cfg.ContainerInfo.Volumes, mountVolumes...)
+
+	payload := cfg.DeployPayload()
+	if assert.NotNil(t, payload.Deploy.ContainerInfo) {
+		assert.Equal(t, mountVolumes, payload.Deploy.ContainerInfo.Volumes)
+	}
+}
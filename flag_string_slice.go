@@ -0,0 +1,18 @@
+package main
+
+import "strings"
+
+// stringslice holds the values of a repeatable string flag, e.g. multiple
+// -var-file flags.
+type stringslice []string
+
+// The first method is String() string
+func (s *stringslice) String() string {
+	return strings.Join(*s, ",")
+}
+
+// The second method is Set(value string) error
+func (s *stringslice) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetFlagStringSlice(t *testing.T) {
+	var s stringslice
+	assert.NoError(t, s.Set("a"))
+	assert.NoError(t, s.Set("b"))
+	assert.Equal(t, stringslice{"a", "b"}, s)
+}
+
+func TestFlagStringSliceString(t *testing.T) {
+	s := stringslice{"a", "b"}
+	assert.Equal(t, "a,b", s.String())
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"strings"
 )
 
@@ -23,10 +22,9 @@ func (s *stringmap) String() string {
 // The second method is Set(value string) error
 func (s *stringmap) Set(v string) error {
 	keyvalue := strings.SplitN(v, varDelimeter, 2)
-	if len(keyvalue) == 2 {
-		(*s)[keyvalue[0]] = keyvalue[1]
-	} else {
-		log.Fatalf("There were not two parts to the var: %s - the correct format is: key%svalue", v, varDelimeter)
+	if len(keyvalue) != 2 {
+		return fmt.Errorf("there were not two parts to the var: %s - the correct format is: key%svalue", v, varDelimeter)
 	}
+	(*s)[keyvalue[0]] = keyvalue[1]
 	return nil
 }
@@ -15,6 +15,12 @@ func TestSetFlagStringStringMap(t *testing.T) {
 	assert.Equal(t, "no", sm["CELERY"])
 }
 
+func TestSetFlagStringStringMapBadFormat(t *testing.T) {
+	var sm = make(stringmap)
+	err := sm.Set("NODELIMETER")
+	assert.Error(t, err)
+}
+
 func TestIterateFlagStringStringMap(t *testing.T) {
 	var sm = make(stringmap)
 	sm.Set("CONSUL=yes")
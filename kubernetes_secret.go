@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// inClusterServiceAccountDir is where Kubernetes mounts a pod's service
+// account credentials. It's a var rather than a const so tests can point it
+// at a fixture directory.
+var inClusterServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubeSecretManifest mirrors the fields of a Kubernetes Secret object that
+// we care about - the base64-encoded "data" map. It is used both to parse
+// a local manifest file and a response from the Kubernetes API.
+type kubeSecretManifest struct {
+	Data map[string]string `yaml:"data" json:"data"`
+}
+
+// decodeSecretData base64-decodes every value in a Secret's "data" map, as
+// Kubernetes always stores/returns Secret data base64-encoded.
+func decodeSecretData(data map[string]string) (map[string]string, error) {
+	decoded := make(map[string]string, len(data))
+	for k, v := range data {
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("decoding secret key %s: %w", k, err)
+		}
+		decoded[k] = string(b)
+	}
+	return decoded, nil
+}
+
+// kubeClient is a minimal Kubernetes API client, capable only of fetching
+// a single Secret. It deliberately avoids pulling in client-go - this tool
+// only ever needs this one read.
+type kubeClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// newKubeClient builds a kubeClient using in-cluster credentials if
+// available, falling back to the current kubeconfig context.
+func newKubeClient() (*kubeClient, error) {
+	if host := os.Getenv("KUBERNETES_SERVICE_HOST"); host != "" {
+		return newInClusterKubeClient(host, os.Getenv("KUBERNETES_SERVICE_PORT"))
+	}
+	return newKubeconfigClient()
+}
+
+func newInClusterKubeClient(host, port string) (*kubeClient, error) {
+	token, err := ioutil.ReadFile(filepath.Join(inClusterServiceAccountDir, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("reading in-cluster service account token: %w", err)
+	}
+
+	caCert, err := ioutil.ReadFile(filepath.Join(inClusterServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("reading in-cluster CA certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("no valid certificates found in in-cluster CA certificate")
+	}
+
+	return &kubeClient{
+		baseURL: fmt.Sprintf("https://%s:%s", host, port),
+		token:   string(token),
+		httpClient: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// kubeconfig mirrors the small subset of a kubeconfig file's fields needed
+// to talk to the API server with a bearer token.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                string `yaml:"server"`
+			InsecureSkipTLSVerify bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token string `yaml:"token"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+func newKubeconfigClient() (*kubeClient, error) {
+	path := os.Getenv("KUBECONFIG")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("determining home directory for kubeconfig: %w", err)
+		}
+		path = filepath.Join(home, ".kube", "config")
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfig %s: %w", path, err)
+	}
+
+	var cfg kubeconfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig %s: %w", path, err)
+	}
+
+	var clusterName, userName string
+	for _, c := range cfg.Contexts {
+		if c.Name == cfg.CurrentContext {
+			clusterName, userName = c.Context.Cluster, c.Context.User
+		}
+	}
+
+	var server string
+	var insecure bool
+	for _, c := range cfg.Clusters {
+		if c.Name == clusterName {
+			server, insecure = c.Cluster.Server, c.Cluster.InsecureSkipTLSVerify
+		}
+	}
+
+	if server == "" {
+		return nil, fmt.Errorf("no cluster found for current context %q in kubeconfig %s", cfg.CurrentContext, path)
+	}
+
+	var token string
+	for _, u := range cfg.Users {
+		if u.Name == userName {
+			token = u.User.Token
+		}
+	}
+
+	return &kubeClient{
+		baseURL: server,
+		token:   token,
+		httpClient: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}},
+		},
+	}, nil
+}
+
+// GetSecret fetches a Secret from the Kubernetes API and returns its data,
+// base64-decoded.
+func (k *kubeClient) GetSecret(namespace, name string) (map[string]string, error) {
+	url := fmt.Sprintf("%s/api/v1/namespaces/%s/secrets/%s", k.baseURL, namespace, name)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for secret %s: %w", name, err)
+	}
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+
+	client := k.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching secret %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret %s response: %w", name, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubernetes API returned %d fetching secret %s: %s", resp.StatusCode, name, string(body))
+	}
+
+	var manifest kubeSecretManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing secret %s response: %w", name, err)
+	}
+
+	return decodeSecretData(manifest.Data)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCACert is a throwaway self-signed certificate, used only to exercise
+// the in-cluster CA-loading path.
+const testCACert = `-----BEGIN CERTIFICATE-----
+MIIDBTCCAe2gAwIBAgIULwvnqfzoVvpapwHxbvE/xL6I6BswDQYJKoZIhvcNAQEL
+BQAwEjEQMA4GA1UEAwwHdGVzdC1jYTAeFw0yNjA3MjYxNTA2MzNaFw0zNjA3MjMx
+NTA2MzNaMBIxEDAOBgNVBAMMB3Rlc3QtY2EwggEiMA0GCSqGSIb3DQEBAQUAA4IB
+DwAwggEKAoIBAQCQmDjpnF2uKL+FYi2ht7azwwknvqC6mJmctoOvBvf7kbuJquTC
+3I8hKVxGhTeyte2rOpw2U5wufEQd9vlJTW28yph1EUamiLmOCW5PglqXx/rxsgm1
+AbUlFu1QGWrvmqdvhG25hIUBV3gafxU9ianYlcPo5IppVvTow7Sir0lH//T4iP0n
+SZfh62RGrLRbHKCvl/zkfHWYe7T9vJ2b/ar6F98+g0xPQmr8I94fKK0oevsdriId
+8YzhKvFulShWqDEwbSoCOEnlr/dGloiEiuHn1l8NrpPgGoZ7fZV+Gx9juZyYB7Uu
+xYsYJbQYO5to/WN3U+TmdL5BgpJz0JNOxaqLAgMBAAGjUzBRMB0GA1UdDgQWBBSq
+l7xKiM42+WCdqOvNeuU1R2OPeDAfBgNVHSMEGDAWgBSql7xKiM42+WCdqOvNeuU1
+R2OPeDAPBgNVHRMBAf8EBTADAQH/MA0GCSqGSIb3DQEBCwUAA4IBAQAYqNg9uC8B
+GHUNV6B+Q5bLtlVEsEhFV06+iaZ3Vaxx055TcOf9O3GruHxzueuPWItjnbDNzGKY
+kqb4tsiWqfB1CrOUNDQBHKlipdAJi/ABFeWznuq5q2FUmV8VW5XfPHo0acDMnda5
+75GvprZNqYdy1D2xENXwJ4NDj1vtuCzEmp5+Mj9PFhUBiYbG7UtV5w+aZnoEALtA
+E8Oxs7Y2+kenQ7eeOcpbkVe4tWlq2VK73bGKKxmsIKkxXOQl+UGnO/ybvKEXpgr+
+e/ricL6H2WRnBd4Qd/M+a+GM831ox2Ke28PDwcEN1dV+SuQU4ZqaVkpNo91ks9J5
+XufGCH1g8/md
+-----END CERTIFICATE-----
+`
+
+func TestDecodeSecretData(t *testing.T) {
+	encoded := map[string]string{"password": base64.StdEncoding.EncodeToString([]byte("hunter2"))}
+
+	decoded, err := decodeSecretData(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", decoded["password"])
+}
+
+func TestDecodeSecretDataRejectsBadBase64(t *testing.T) {
+	_, err := decodeSecretData(map[string]string{"password": "not-base64!!"})
+	assert.Error(t, err)
+}
+
+func TestKubeClientGetSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/default/secrets/my-secret", r.URL.Path)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"data":{"password":"` + base64.StdEncoding.EncodeToString([]byte("hunter2")) + `"}}`))
+	}))
+	defer server.Close()
+
+	client := &kubeClient{baseURL: server.URL, token: "test-token", httpClient: server.Client()}
+
+	vars, err := client.GetSecret("default", "my-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", vars["password"])
+}
+
+func TestKubeClientGetSecretRejectsNonOKStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := &kubeClient{baseURL: server.URL, httpClient: server.Client()}
+
+	_, err := client.GetSecret("default", "missing")
+	assert.Error(t, err)
+}
+
+func TestNewKubeconfigClientParsesCurrentContext(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`
+current-context: my-context
+contexts:
+  - name: my-context
+    context:
+      cluster: my-cluster
+      user: my-user
+clusters:
+  - name: my-cluster
+    cluster:
+      server: https://cluster.example.com
+      insecure-skip-tls-verify: true
+users:
+  - name: my-user
+    user:
+      token: test-token
+`), 0644))
+
+	t.Setenv("KUBECONFIG", path)
+
+	client, err := newKubeconfigClient()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://cluster.example.com", client.baseURL)
+	assert.Equal(t, "test-token", client.token)
+}
+
+func TestNewKubeconfigClientMissingCluster(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`
+current-context: my-context
+contexts:
+  - name: my-context
+    context:
+      cluster: does-not-exist
+      user: my-user
+`), 0644))
+
+	t.Setenv("KUBECONFIG", path)
+
+	_, err := newKubeconfigClient()
+	assert.Error(t, err)
+}
+
+func TestNewInClusterKubeClientReadsTokenAndCA(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("in-cluster-token"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ca.crt"), []byte(testCACert), 0644))
+
+	previous := inClusterServiceAccountDir
+	inClusterServiceAccountDir = dir
+	defer func() { inClusterServiceAccountDir = previous }()
+
+	client, err := newInClusterKubeClient("kubernetes.default.svc", "443")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://kubernetes.default.svc:443", client.baseURL)
+	assert.Equal(t, "in-cluster-token", client.token)
+}
+
+func TestNewInClusterKubeClientMissingToken(t *testing.T) {
+	previous := inClusterServiceAccountDir
+	inClusterServiceAccountDir = t.TempDir()
+	defer func() { inClusterServiceAccountDir = previous }()
+
+	_, err := newInClusterKubeClient("kubernetes.default.svc", "443")
+	assert.Error(t, err)
+}
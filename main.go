@@ -1,20 +1,21 @@
 package main // import "github.com/nutmegdevelopment/singularity-config-generator"
 
 import (
-	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"text/template"
 
 	log "github.com/Sirupsen/logrus"
-	yaml "gopkg.in/yaml.v2"
+	"go.uber.org/multierr"
+
+	"github.com/nutmegdevelopment/singularity-config-generator/singularity"
 )
 
 const (
 	defaultConfigFile                            = "singularity.yml"
+	defaultModuleCacheDir                        = ".singularity-config-modules"
 	scheduledExpectedRuntimeMillisDefault        = 360000
 	killOldNonLongRunningTasksAfterMillisDefault = 10000
 
@@ -26,9 +27,23 @@ const (
 var (
 	debug           = false
 	configFile      string
-	deployTemplate  *template.Template
-	requestTemplate *template.Template
+	moduleCacheDir  string
 	commandLineVars = make(stringmap)
+
+	varFiles           stringslice
+	varEnvPrefixes     stringslice
+	varSecrets         stringslice
+	varSecretFiles     stringslice
+	varSecretNamespace string
+
+	submit             = false
+	endpoint           string
+	token              string
+	user               string
+	password           string
+	insecureSkipVerify = false
+
+	strict = false
 )
 
 // SingularityConfigData is used to store the config yaml template data
@@ -49,20 +64,25 @@ type SingularityConfig struct {
 	RequestID                             string `yaml:"request-id"`
 	Arguments                             []string
 	ContainerInfo                         SingularityContainerInfo `yaml:"container-info,omitempty"`
-	Resources                             struct {
-		NumPorts int     `yaml:"num-ports" json:"numPorts,omitempty"`
-		MemoryMb float64 `yaml:"memory-mb" json:"memoryMb,omitempty"`
-		CPUs     float64 `yaml:"cpus" json:"cpus,omitempty"`
-		DiskMb   float64 `yaml:"disk-mb" json:"diskMb,omitempty"`
-	} `json:"resources"`
-	URIs []string `yaml:"uris"`
+	Resources                             SingularityResources
+	URIs                                  []string                      `yaml:"uris"`
+	Healthcheck                           SingularityHealthcheckOptions `yaml:"healthcheck,omitempty"`
+	Mounts                                []SingularityMount            `yaml:"mounts,omitempty"`
+}
+
+// SingularityResources holds the resource requirements of a deploy.
+type SingularityResources struct {
+	NumPorts int     `yaml:"num-ports" json:"numPorts,omitempty"`
+	MemoryMb float64 `yaml:"memory-mb" json:"memoryMb,omitempty"`
+	CPUs     float64 `yaml:"cpus" json:"cpus,omitempty"`
+	DiskMb   float64 `yaml:"disk-mb" json:"diskMb,omitempty"`
 }
 
 // SingularityPortMapping - see:
 // https://github.com/HubSpot/Singularity/blob/master/Docs/reference/api.md#model-SingularityPortMapping
 type SingularityPortMapping struct {
 	HostPort          int                        `yaml:"hostPort" json:"hostPort"`
-	ContainerPort     int                        `yaml:"containerPort" json:"containerPort" json:"containerPort"`
+	ContainerPort     int                        `yaml:"containerPort" json:"containerPort"`
 	ContainerPortType SingularityPortMappingType `yaml:"containerPortType,omitempty" json:"containerPortType,omitempty"`
 	Protocol          string                     `yaml:"protocol,omitempty" json:"protocol,omitempty"`
 	HostPortType      SingularityPortMappingType `yaml:"hostPortType,omitempty" json:"hostPortType,omitempty"`
@@ -70,23 +90,48 @@ type SingularityPortMapping struct {
 
 // SingularityPortMappingType - see:
 // https://github.com/HubSpot/Singularity/blob/master/Docs/reference/api.md#model-SingularityPortMappingType
-type SingularityPortMappingType struct {
-}
+type SingularityPortMappingType string
+
+// The port mapping types Singularity accepts: LITERAL uses hostPort/
+// containerPort as given, FROM_OFFSET treats them as an offset from the
+// task's allocated port range.
+const (
+	PortMappingLiteral    SingularityPortMappingType = "LITERAL"
+	PortMappingFromOffset SingularityPortMappingType = "FROM_OFFSET"
+)
 
 // SingularityVolume - see:
 // https://github.com/HubSpot/Singularity/blob/master/Docs/reference/api.md#model-SingularityVolume
 type SingularityVolume struct {
-	HostPath      string `json:"hostPath,omitempty"`
-	ContainerPath string `json:"containerPath,omitempty"`
-	Mode          string `json:"mode,omitempty"`
+	HostPath      string `yaml:"hostPath,omitempty" json:"hostPath,omitempty"`
+	ContainerPath string `yaml:"containerPath,omitempty" json:"containerPath,omitempty"`
+	Mode          string `yaml:"mode,omitempty" json:"mode,omitempty"`
+}
+
+// SingularityHealthcheckOptions - see:
+// https://github.com/HubSpot/Singularity/blob/master/Docs/reference/api.md#model-HealthcheckOptions
+type SingularityHealthcheckOptions struct {
+	URI                    string `yaml:"uri" json:"uri"`
+	PortIndex              int    `yaml:"portIndex,omitempty" json:"portIndex,omitempty"`
+	PortNumber             int    `yaml:"portNumber,omitempty" json:"portNumber,omitempty"`
+	StartupTimeoutSeconds  int    `yaml:"startupTimeoutSeconds,omitempty" json:"startupTimeoutSeconds,omitempty"`
+	StartupDelaySeconds    int    `yaml:"startupDelaySeconds,omitempty" json:"startupDelaySeconds,omitempty"`
+	StartupIntervalSeconds int    `yaml:"startupIntervalSeconds,omitempty" json:"startupIntervalSeconds,omitempty"`
+	IntervalSeconds        int    `yaml:"intervalSeconds,omitempty" json:"intervalSeconds,omitempty"`
+	ResponseTimeoutSeconds int    `yaml:"responseTimeoutSeconds,omitempty" json:"responseTimeoutSeconds,omitempty"`
+	MaxRetries             int    `yaml:"maxRetries,omitempty" json:"maxRetries,omitempty"`
+	Protocol               string `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+	Method                 string `yaml:"method,omitempty" json:"method,omitempty"`
 }
 
 // Init ...
 func (s *SingularityConfig) Init() {
 	// Initialize fields that you do NOT want to have 'null' values,
 	// like slices where you want to see '[]' in the JSON when it is
-	// empty.
-	s.ContainerInfo.Volumes = make([]SingularityVolume, 0)
+	// empty. Only set this if a module hasn't already populated it.
+	if s.ContainerInfo.Volumes == nil {
+		s.ContainerInfo.Volumes = make([]SingularityVolume, 0)
+	}
 
 	// Set any default values.
 	if s.ScheduledExpectedRuntimeMillis == 0 {
@@ -97,44 +142,88 @@ func (s *SingularityConfig) Init() {
 	}
 }
 
-// SingularityRequestTemplate ...
-// Make sure that guaranteed items are at the end - like id - so that preceeding
-// elements can add a trailing comma "," if they exist.
-const SingularityRequestTemplate = `
-{
-    {{.WriteOwners -}}
-	{{.WriteRequiredSlaveAttributes -}}
-	{{.WriteSchedule -}}
-	"killOldNonLongRunningTasksAfterMillis": {{.KillOldNonLongRunningTasksAfterMillis}},
-	"numRetriesOnFailure": {{.NumRetriesOnFailure}},
-	"requestType": "{{.RequestType -}}",
-    "scheduledExpectedRuntimeMillis": {{.ScheduledExpectedRuntimeMillis}},
-    "id": "{{.RequestID -}}"
+// SingularityRequestPayload is the JSON body POSTed to Singularity's
+// /api/requests endpoint. Build one from a SingularityConfig via
+// SingularityConfig.RequestPayload.
+type SingularityRequestPayload struct {
+	Owners                                []string          `json:"owners,omitempty"`
+	RequiredSlaveAttributes               map[string]string `json:"requiredSlaveAttributes,omitempty"`
+	Schedule                              string            `json:"schedule,omitempty"`
+	KillOldNonLongRunningTasksAfterMillis int               `json:"killOldNonLongRunningTasksAfterMillis"`
+	NumRetriesOnFailure                   int               `json:"numRetriesOnFailure"`
+	RequestType                           string            `json:"requestType"`
+	ScheduledExpectedRuntimeMillis        int               `json:"scheduledExpectedRuntimeMillis"`
+	ID                                    string            `json:"id"`
 }
-`
-
-// SingularityDeployTemplate ...
-// Make sure that guaranteed items are at the end - like id - so that preceeding
-// elements can add a trailing comma "," if they exist.
-const SingularityDeployTemplate = `
-{
-    "deploy": {
-        {{.WriteArguments}}
-		{{.WriteContainerInfo}}
-		{{.WriteEnv}}
-        {{.WriteResources}}
-        "requestId": "{{.RequestID}}",
-        "id": "{{.DeployID}}"
-    }
+
+// RequestPayload builds the JSON payload for Singularity's /api/requests
+// endpoint from the config.
+func (s SingularityConfig) RequestPayload() SingularityRequestPayload {
+	return SingularityRequestPayload{
+		Owners:                                s.Owners,
+		RequiredSlaveAttributes:               s.RequiredSlaveAttributes,
+		Schedule:                              s.Schedule,
+		KillOldNonLongRunningTasksAfterMillis: s.KillOldNonLongRunningTasksAfterMillis,
+		NumRetriesOnFailure:                   s.NumRetriesOnFailure,
+		RequestType:                           s.RequestType,
+		ScheduledExpectedRuntimeMillis:        s.ScheduledExpectedRuntimeMillis,
+		ID:                                    s.RequestID,
+	}
+}
+
+// SingularityDeployPayload is the JSON body POSTed to Singularity's
+// /api/deploys/pending endpoint. Build one from a SingularityConfig via
+// SingularityConfig.DeployPayload.
+type SingularityDeployPayload struct {
+	Deploy SingularityDeploy `json:"deploy"`
+}
+
+// SingularityDeploy holds the fields nested under "deploy" in a deploy
+// payload.
+type SingularityDeploy struct {
+	Arguments     []string                       `json:"arguments,omitempty"`
+	ContainerInfo *SingularityContainerInfo      `json:"containerInfo,omitempty"`
+	Env           map[string]string              `json:"env,omitempty"`
+	Healthcheck   *SingularityHealthcheckOptions `json:"healthcheck,omitempty"`
+	Resources     SingularityResources           `json:"resources"`
+	RequestID     string                         `json:"requestId"`
+	ID            string                         `json:"id"`
+}
+
+// DeployPayload builds the JSON payload for Singularity's
+// /api/deploys/pending endpoint from the config. ContainerInfo and
+// Healthcheck are only included if the config actually declares them, to
+// avoid sending empty blocks.
+func (s SingularityConfig) DeployPayload() SingularityDeployPayload {
+	var containerInfo *SingularityContainerInfo
+	if s.ContainerInfo.Type != "" {
+		containerInfo = &s.ContainerInfo
+	}
+
+	var healthcheck *SingularityHealthcheckOptions
+	if s.Healthcheck.URI != "" {
+		healthcheck = &s.Healthcheck
+	}
+
+	return SingularityDeployPayload{
+		Deploy: SingularityDeploy{
+			Arguments:     s.Arguments,
+			ContainerInfo: containerInfo,
+			Env:           s.Env,
+			Healthcheck:   healthcheck,
+			Resources:     s.Resources,
+			RequestID:     s.RequestID,
+			ID:            s.DeployID,
+		},
+	}
 }
-`
 
 // SingularityContainerInfo - see:
 // https://github.com/HubSpot/Singularity/blob/master/Docs/reference/api.md#model-SingularityContainerInfo
 type SingularityContainerInfo struct {
-	Docker  SingularityDockerInfo `json:"docker"`
-	Type    string                `json:"type"`
-	Volumes []SingularityVolume   `json:"volumes,omitempty"`
+	Docker  SingularityDockerInfo `yaml:"docker" json:"docker"`
+	Type    string                `yaml:"type" json:"type"`
+	Volumes []SingularityVolume   `yaml:"volumes,omitempty" json:"volumes,omitempty"`
 }
 
 // SingularityDockerInfo - see:
@@ -149,100 +238,15 @@ type SingularityDockerInfo struct {
 	PortMappings     []SingularityPortMapping `yaml:"portMappings,omitempty" json:"portMappings,omitempty"`
 }
 
-// WriteContainerInfo handles adding a containerInfo section if one is
-// required.  Internal sections are only added if they are needed to avoid
-// having null values.
-func (s SingularityConfig) WriteContainerInfo() string {
-	if s.ContainerInfo.Type == "" {
-		return ""
-	}
-
-	return marshalJSON("containerInfo", s.ContainerInfo)
-}
-
-// marshalJSON takes an element name and an interface.  The interface is
-// marshalled into a JSON string and appended to the element name to
-// create a "key": "value", pair.
-// A trailing comma is always added as elements written using this method
-// are not expected to be the last elements in the JSON object (the 'id'
-// element is always last to allow trailing commas to now break the JSON).
-func marshalJSON(elementName string, i interface{}) string {
-	j, err := json.Marshal(i)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-			"data":  fmt.Sprintf("%+v", i),
-		}).Error("Error marshalling to json string")
-	}
-
-	return fmt.Sprintf(`"%s": %s,`, elementName, string(j))
-}
-
-// WriteOwners ...
-func (s SingularityConfig) WriteOwners() string {
-	if len(s.Owners) == 0 {
-		return ""
-	}
-
-	return marshalJSON("owners", s.Owners)
-}
-
-// WriteResources is a map with
-func (s SingularityConfig) WriteResources() string {
-	return marshalJSON("resources", s.Resources)
-}
-
-// WriteSchedule ...
-func (s SingularityConfig) WriteSchedule() string {
-	if s.Schedule == "" {
-		return ""
-	}
-	return marshalJSON("schedule", s.Schedule)
-}
-
-// WriteEnv ...
-func (s SingularityConfig) WriteEnv() string {
-	if len(s.Env) == 0 {
-		return ""
-	}
-	return marshalJSON("env", s.Env)
-}
-
-// WriteRequiredSlaveAttributes ...
-func (s SingularityConfig) WriteRequiredSlaveAttributes() string {
-	if s.RequiredSlaveAttributes == nil {
-		return ""
-	}
-	return marshalJSON("requiredSlaveAttributes", s.RequiredSlaveAttributes)
-
-}
-
-// WriteArguments ...
-func (s SingularityConfig) WriteArguments() string {
-	if len(s.Arguments) == 0 {
-		return ""
-	}
-	return marshalJSON("arguments", s.Arguments)
-}
-
 // Read in a file.
 func readFile(filename string) ([]byte, error) {
 	b, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("reading file %s: %w", filename, err)
 	}
 	return b, nil
 }
 
-// Read in a file and fatal error if there is a problem.
-func readFileOrDie(filename string) []byte {
-	b, err := readFile(filename)
-	if err != nil {
-		log.Fatalf("Unable to read file: %s. %s", filename, err)
-	}
-	return b
-}
-
 // Write a file to the local filesystem.  Return an error if unsuccessful.
 func writeFile(filename string, b []byte) error {
 	f, err := os.Create(filename)
@@ -264,151 +268,174 @@ func writeFile(filename string, b []byte) error {
 	return nil
 }
 
-// checkJSON tries to unmarshal the provided JSON into an interface{} - if
-// not successful then the generated error is returned.
-func checkJSON(b []byte) error {
-	var iface interface{}
-	err := json.Unmarshal(b, &iface)
-	if err != nil {
-		return err
-	}
-
-	log.WithFields(log.Fields{
-		"json": string(b),
-	}).Debug("JSON is valid")
-
-	return nil
-}
-
-func init() {
-	var err error
-	requestTemplate, err = template.New("Request template").Parse(SingularityRequestTemplate)
+// process marshals payload to JSON, validates it against our hand-written
+// partial JSON schema named schemaName (e.g. "request", "deploy"), and
+// writes it to a local file. It returns the generated JSON so callers can
+// also submit it elsewhere.
+func process(schemaName string, payload interface{}, filename string) ([]byte, error) {
+	jsonOutput, err := json.Marshal(payload)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
-		}).Fatal("Unable to parse the request template")
-	}
-	deployTemplate, err = template.New("Deploy template").Parse(SingularityDeployTemplate)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Fatal("Unable to parse the deploy template")
-	}
-}
-
-// process performs three functions:
-// 1, generates JSON from the provided template and SingularityConfig instance.
-// 2, checks that the generated JSON is valid JSON.
-// 3, writes the JSON to a local file.
-func process(tmpl *template.Template, singularityConfig SingularityConfig, filename string) error {
-	var jsonOutput = new(bytes.Buffer)
-
-	// Create the JSON
-	err := tmpl.Execute(jsonOutput, singularityConfig)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Error("Unable to execute the template")
-		return err
+		}).Error("Unable to marshal JSON")
+		return nil, err
 	}
 	log.WithFields(log.Fields{
-		"json": jsonOutput.String(),
+		"json": string(jsonOutput),
 	}).Debug("Generated JSON")
 
-	// Check that the JSON is valid.
-	err = checkJSON(jsonOutput.Bytes())
+	// Validate against the bundled schema.
+	err = validateSchema(schemaName, jsonOutput)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
-			"json":  jsonOutput.String(),
-		}).Error("Invalid request JSON")
-		return err
+			"json":  string(jsonOutput),
+		}).Error("Generated JSON failed schema validation")
+		return nil, err
 	}
 
 	// Write the JSON to a file.
-	err = writeFile(filename, jsonOutput.Bytes())
+	err = writeFile(filename, jsonOutput)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
 		}).Error("Unable to write JSON file")
-		return err
+		return nil, err
 	}
 
-	return nil
+	return jsonOutput, nil
 }
 
-func loadConfig() SingularityConfig {
-	var singularityConfig SingularityConfig
-	singularityConfig.Init()
-
-	// Load the config through the go templating engine
-	configTemplate, err := template.ParseFiles(configFile)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Fatal("Unable to parse the config file (go template)")
+// submitToSingularity pushes the generated request/deploy JSON to a running
+// Singularity scheduler. It runs after process has already written both
+// files to disk; submitting never skips writing them.
+func submitToSingularity(requestJSON, deployJSON []byte) error {
+	client := singularity.NewClient(endpoint)
+	client.Token = token
+	client.Username = user
+	client.Password = password
+	client.InsecureSkipVerify = insecureSkipVerify
+
+	if err := client.SubmitRequest(requestJSON); err != nil {
+		return fmt.Errorf("submitting request: %w", err)
 	}
 
-	// Load vars from the command line
-	var singularityConfigData SingularityConfigData
-	for k, v := range commandLineVars {
-		singularityConfigData[k] = v
+	if err := client.SubmitDeploy(deployJSON); err != nil {
+		return fmt.Errorf("submitting deploy: %w", err)
 	}
 
-	// Exexute the template with the provided vars
-	var rawConfig = new(bytes.Buffer)
-	err = configTemplate.Execute(rawConfig, singularityConfigData)
+	return nil
+}
+
+// loadConfig reads configFile, renders it (and any modules it includes) as
+// Go templates using variables gathered from the configured VarProviders,
+// and deep-merges the results into a single SingularityConfig.
+func loadConfig() (SingularityConfig, error) {
+	// Gather vars from every configured provider.
+	singularityConfigData, err := mergeVarProviders(varProviders())
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Fatal("Failed to execute config template")
+		return SingularityConfig{}, fmt.Errorf("loading template vars: %w", err)
 	}
-	log.WithFields(log.Fields{
-		"templateResult": rawConfig,
-	}).Debug("Templated the config file")
 
-	// Unmarshal the templated YAML config.
-	err = yaml.Unmarshal(rawConfig.Bytes(), &singularityConfig)
+	loader := newModuleLoader(moduleCacheDir)
+	singularityConfig, err := loader.load(configFile, ".", singularityConfigData, nil)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"filename":              configFile,
-			"error":                 err,
-			"message":               "Check that all expected replacements have been correctly applied",
-			"yaml-after-templating": rawConfig.String(),
-		}).Fatal("Unable to unmarshal config file")
+		return SingularityConfig{}, fmt.Errorf("loading config %s: %w", configFile, err)
 	}
+
+	singularityConfig.Init()
+
 	log.WithFields(log.Fields{
-		"config": singularityConfig,
-	}).Debug("Unmarshalled config")
+		"config":     singularityConfig,
+		"provenance": loader.provenance,
+	}).Debug("Loaded config")
 
-	return singularityConfig
+	return singularityConfig, nil
 }
 
 func main() {
 	flag.BoolVar(&debug, "debug", false, "debug output.")
 	flag.StringVar(&configFile, "config-file", defaultConfigFile, "The name of the config file")
+	flag.StringVar(&moduleCacheDir, "module-cache-dir", defaultModuleCacheDir, "Directory used to cache modules included via a git:: or http(s):// source.")
 	flag.Var(&commandLineVars, "var", "[] of variables in the form of: key=value - multiple -var flags can be used, one per key/value pair.")
+	flag.Var(&varFiles, "var-file", "Path to a YAML or JSON file of variables to bulk-import. Multiple -var-file flags can be used.")
+	flag.Var(&varEnvPrefixes, "var-env", "Import every environment variable with this prefix, stripped of the prefix. Multiple -var-env flags can be used.")
+	flag.Var(&varSecrets, "var-secret", "Name of a Kubernetes Secret to fetch from the cluster (in-cluster or via kubeconfig) and import, base64-decoded. Multiple -var-secret flags can be used.")
+	flag.Var(&varSecretFiles, "var-secret-file", "Path to a local Kubernetes Secret manifest to import, base64-decoded. Multiple -var-secret-file flags can be used.")
+	flag.StringVar(&varSecretNamespace, "var-secret-namespace", "default", "Namespace to fetch -var-secret Secrets from.")
+	flag.BoolVar(&submit, "submit", false, "Submit the generated request/deploy JSON to a running Singularity scheduler, in addition to writing it to disk.")
+	flag.StringVar(&endpoint, "endpoint", "", "The base URL of the Singularity scheduler to submit to, e.g. http://singularity.example.com. Required if -submit is set.")
+	flag.StringVar(&token, "token", "", "Bearer token used to authenticate with the Singularity scheduler when -submit is set.")
+	flag.StringVar(&user, "user", "", "Username used for basic auth with the Singularity scheduler when -submit is set and -token is not used.")
+	flag.StringVar(&password, "password", "", "Password used for basic auth with the Singularity scheduler when -submit is set and -token is not used.")
+	flag.BoolVar(&insecureSkipVerify, "insecure", false, "Skip TLS certificate verification when submitting to the Singularity scheduler. Only use this against schedulers with self-signed certificates.")
+	flag.BoolVar(&strict, "strict", false, "Fail on the first error encountered. By default all processing steps are attempted and every error is reported together.")
 	flag.Parse()
 
 	if debug {
 		log.SetLevel(log.DebugLevel)
 	}
 
-	singularityConfig := loadConfig()
+	if submit && endpoint == "" {
+		log.Fatal("-endpoint must be set when -submit is used")
+	}
 
-	err := process(requestTemplate, singularityConfig, requestFilename)
+	singularityConfig, err := loadConfig()
 	if err != nil {
 		log.WithFields(log.Fields{
-			"error":    err,
-			"filename": requestFilename,
-		}).Fatal("Unrecoverable error occurred processing request")
+			"error": err,
+		}).Fatal("Unable to load config")
+	}
+
+	if len(singularityConfig.Mounts) > 0 {
+		if err := validateMounts(singularityConfig); err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Fatal("Invalid mounts configuration")
+		}
+
+		mountVolumes, err := generatePasswdGroupFiles(singularityConfig.Mounts, ".")
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Fatal("Unable to generate passwd/group mount files")
+		}
+		singularityConfig.ContainerInfo.Volumes = append(singularityConfig.ContainerInfo.Volumes, mountVolumes...)
+	}
+
+	var errs error
+
+	requestJSON, err := process("request", singularityConfig.RequestPayload(), requestFilename)
+	if err != nil {
+		err = fmt.Errorf("processing request: %w", err)
+		if strict {
+			log.WithFields(log.Fields{"error": err}).Fatal("Unrecoverable error occurred")
+		}
+		errs = multierr.Append(errs, err)
 	}
 
-	err = process(deployTemplate, singularityConfig, deployFilename)
+	deployJSON, err := process("deploy", singularityConfig.DeployPayload(), deployFilename)
 	if err != nil {
+		err = fmt.Errorf("processing deploy: %w", err)
+		if strict {
+			log.WithFields(log.Fields{"error": err}).Fatal("Unrecoverable error occurred")
+		}
+		errs = multierr.Append(errs, err)
+	}
+
+	if errs != nil {
 		log.WithFields(log.Fields{
-			"error":    err,
-			"filename": deployFilename,
-		}).Fatal("Unrecoverable error occurred processing deploy")
+			"error": errs,
+		}).Fatal("Unrecoverable errors occurred")
+	}
+
+	if submit {
+		err = submitToSingularity(requestJSON, deployJSON)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":    err,
+				"endpoint": endpoint,
+			}).Fatal("Unable to submit config to Singularity scheduler")
+		}
 	}
 }
@@ -1,50 +1,115 @@
 package main
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestMakeStringJSONSafe(t *testing.T) {
-	s := `{"i":"am","a":"test"}`
-	sSafe := `{\"i\":\"am\",\"a\":\"test\"}`
-	s = makeStringJSONSafe(s)
-	assert.Equal(t, s, sSafe)
+func TestRequestPayload(t *testing.T) {
+	cfg := SingularityConfig{
+		RequestID:   "my-request",
+		RequestType: "SERVICE",
+		Owners:      []string{"team@example.com"},
+	}
+
+	payload := cfg.RequestPayload()
+
+	assert.Equal(t, "my-request", payload.ID)
+	assert.Equal(t, "SERVICE", payload.RequestType)
+	assert.Equal(t, []string{"team@example.com"}, payload.Owners)
+}
+
+func TestDeployPayloadOmitsContainerInfoWhenUnset(t *testing.T) {
+	cfg := SingularityConfig{
+		RequestID: "my-request",
+		DeployID:  "my-deploy",
+	}
+
+	payload := cfg.DeployPayload()
+
+	assert.Nil(t, payload.Deploy.ContainerInfo)
+	assert.Equal(t, "my-request", payload.Deploy.RequestID)
+	assert.Equal(t, "my-deploy", payload.Deploy.ID)
 }
 
-func TestWriteMap(t *testing.T) {
-	m := map[string]string{"key1": "value1", "key2": "value2"}
-	s := `"element": {"key1":"value1","key2":"value2"},`
-	s2 := WriteMap("element", m)
-	assert.Equal(t, s, s2)
+func TestDeployPayloadIncludesContainerInfoWhenSet(t *testing.T) {
+	cfg := SingularityConfig{
+		RequestID: "my-request",
+		DeployID:  "my-deploy",
+		ContainerInfo: SingularityContainerInfo{
+			Type: "DOCKER",
+		},
+	}
+
+	payload := cfg.DeployPayload()
+
+	if assert.NotNil(t, payload.Deploy.ContainerInfo) {
+		assert.Equal(t, "DOCKER", payload.Deploy.ContainerInfo.Type)
+	}
+}
+
+func TestValidateSchemaRejectsInvalidRequest(t *testing.T) {
+	err := validateSchema("request", []byte(`{"id": ""}`))
+	assert.Error(t, err)
 }
 
-func TestWriteMapItems(t *testing.T) {
-	m := map[string]string{"key1": "value1", "key2": "value2"}
-	s := `"key1":"value1","key2":"value2"`
-	s2 := WriteMapItems(m)
-	assert.Equal(t, s, s2)
+func TestValidateSchemaAcceptsValidRequest(t *testing.T) {
+	payload := SingularityConfig{RequestID: "my-request", RequestType: "SERVICE"}.RequestPayload()
+	b, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	assert.NoError(t, validateSchema("request", b))
 }
 
-func TestWriteSlice(t *testing.T) {
-	sl := []string{"value1", "value2"}
-	s := `"element": ["value1","value2"],`
-	s2 := WriteSlice("element", sl)
-	assert.Equal(t, s, s2)
+func TestValidateSchemaAcceptsValidDeploy(t *testing.T) {
+	payload := SingularityConfig{RequestID: "my-request", DeployID: "my-deploy"}.DeployPayload()
+	b, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	assert.NoError(t, validateSchema("deploy", b))
 }
 
-func TestWriteSliceItems(t *testing.T) {
-	sl := []string{"value1", "value2"}
-	s := `"value1","value2"`
-	s2 := WriteSliceItems(sl)
-	assert.Equal(t, s, s2)
+func TestValidateSchemaRejectsMalformedJSON(t *testing.T) {
+	err := validateSchema("request", []byte(`{not valid json`))
+	assert.Error(t, err)
 }
 
-func TestReplacePlaceholders(t *testing.T) {
-	testString := "We are the {{what_are_we}}, And we are the {{what_are_we_also}}"
-	expectedOutput := "We are the music makers, And we are the dreamers of dreams"
-	testMap := map[string]string{"what_are_we": "music makers", "what_are_we_also": "dreamers of dreams"}
-	output := replacePlaceholders([]byte(testString), testMap)
-	assert.Equal(t, string(output), expectedOutput)
+func withConfigFile(t *testing.T, contents string) func() {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "singularity.yml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+
+	previous := configFile
+	configFile = path
+	return func() { configFile = previous }
+}
+
+func TestLoadConfigReturnsErrorOnBadTemplate(t *testing.T) {
+	defer withConfigFile(t, `request-id: {{.Unterminated`)()
+
+	_, err := loadConfig()
+	assert.Error(t, err)
+}
+
+func TestLoadConfigReturnsErrorOnInvalidYAML(t *testing.T) {
+	defer withConfigFile(t, "request-id: [this is not valid yaml")()
+
+	_, err := loadConfig()
+	assert.Error(t, err)
+}
+
+func TestLoadConfigReturnsErrorWhenConfigFileMissing(t *testing.T) {
+	previous := configFile
+	configFile = filepath.Join(os.TempDir(), "does-not-exist-singularity.yml")
+	defer func() { configFile = previous }()
+
+	_, err := loadConfig()
+	assert.Error(t, err)
 }
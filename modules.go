@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ModuleInclude is one entry in a config's "modules"/"include" list: a
+// reference to another config fragment, plus the parameters to render it
+// with.
+type ModuleInclude struct {
+	Name string                 `yaml:"name"`
+	With map[string]interface{} `yaml:"with"`
+}
+
+// configDocument is the shape of a config file or module fragment: a
+// SingularityConfig plus the modules it composes. "modules" and "include"
+// are accepted as aliases of one another.
+type configDocument struct {
+	SingularityConfig `yaml:",inline"`
+	Modules           []ModuleInclude `yaml:"modules"`
+	Include           []ModuleInclude `yaml:"include"`
+}
+
+func (d configDocument) includes() []ModuleInclude {
+	return append(append([]ModuleInclude{}, d.Modules...), d.Include...)
+}
+
+// moduleLoader renders and composes a config file and the module fragments
+// it includes into a single SingularityConfig.
+type moduleLoader struct {
+	cacheDir string
+
+	// provenance records, for each merged field, the source (file path or
+	// module name) that last set it - purely for debug logging.
+	provenance map[string]string
+}
+
+func newModuleLoader(cacheDir string) *moduleLoader {
+	return &moduleLoader{
+		cacheDir:   cacheDir,
+		provenance: make(map[string]string),
+	}
+}
+
+// load renders and merges sourceName (a file path, resolved relative to
+// baseDir unless it's absolute or a git::/http(s):// URL) using
+// templateData, then recursively does the same for every module it
+// includes, deep-merging them (and its own fields) into the result.
+// visited tracks the chain of sources currently being resolved, to detect
+// include cycles.
+func (l *moduleLoader) load(sourceName, baseDir string, templateData map[string]interface{}, visited []string) (SingularityConfig, error) {
+	path, err := l.resolveSource(sourceName, baseDir)
+	if err != nil {
+		return SingularityConfig{}, fmt.Errorf("resolving %s: %w", sourceName, err)
+	}
+
+	for _, v := range visited {
+		if v == path {
+			return SingularityConfig{}, fmt.Errorf("cycle detected in module includes: %s", strings.Join(append(visited, path), " -> "))
+		}
+	}
+	visited = append(visited, path)
+
+	rendered, err := renderTemplateFile(path, templateData)
+	if err != nil {
+		return SingularityConfig{}, fmt.Errorf("rendering %s: %w", path, err)
+	}
+
+	var doc configDocument
+	if err := yaml.Unmarshal(rendered, &doc); err != nil {
+		return SingularityConfig{}, fmt.Errorf("unmarshalling %s: %w", path, err)
+	}
+
+	var merged SingularityConfig
+	fragmentBaseDir := filepath.Dir(path)
+	for _, include := range doc.includes() {
+		fragment, err := l.load(include.Name, fragmentBaseDir, include.With, visited)
+		if err != nil {
+			return SingularityConfig{}, err
+		}
+		mergeSingularityConfig(&merged, fragment, l.provenance, include.Name)
+	}
+
+	mergeSingularityConfig(&merged, doc.SingularityConfig, l.provenance, path)
+
+	return merged, nil
+}
+
+func (l *moduleLoader) resolveSource(name, baseDir string) (string, error) {
+	switch {
+	case strings.HasPrefix(name, "git::"):
+		return l.fetchGit(strings.TrimPrefix(name, "git::"))
+	case strings.HasPrefix(name, "https://"), strings.HasPrefix(name, "http://"):
+		return l.fetchHTTP(name)
+	case filepath.IsAbs(name):
+		return name, nil
+	default:
+		return filepath.Join(baseDir, name), nil
+	}
+}
+
+// fetchHTTP downloads url into the module cache dir, reusing a previously
+// cached copy if one already exists.
+func (l *moduleLoader) fetchHTTP(url string) (string, error) {
+	cachePath := filepath.Join(l.cacheDir, cacheKey(url)+".yml")
+	if _, err := os.Stat(cachePath); err == nil {
+		return cachePath, nil
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", url, err)
+	}
+
+	if err := os.MkdirAll(l.cacheDir, 0755); err != nil {
+		return "", fmt.Errorf("creating module cache dir %s: %w", l.cacheDir, err)
+	}
+	if err := ioutil.WriteFile(cachePath, body, 0644); err != nil {
+		return "", fmt.Errorf("writing %s to module cache: %w", cachePath, err)
+	}
+
+	return cachePath, nil
+}
+
+// allowedGitTransports are the only git transports fetchGit will clone
+// over. git also supports "ext::"/"fd::" pseudo-transports that run an
+// arbitrary local command in place of a remote fetch - since module
+// references can come from untrusted, recursively-included fragments,
+// anything outside this allow-list is rejected rather than shelled out to.
+var allowedGitTransports = []string{"https://", "git://", "ssh://"}
+
+// validateGitRepoURL rejects anything that isn't a plain URL using one of
+// allowedGitTransports, so a malicious module can't smuggle a git
+// pseudo-transport (or a leading "-" that would be parsed as a git flag)
+// into the repo argument passed to "git clone".
+func validateGitRepoURL(repoURL string) error {
+	if strings.HasPrefix(repoURL, "-") {
+		return fmt.Errorf("git module repo %q must not start with '-'", repoURL)
+	}
+	for _, transport := range allowedGitTransports {
+		if strings.HasPrefix(repoURL, transport) {
+			return nil
+		}
+	}
+	return fmt.Errorf("git module repo %q does not use an allowed transport (%s)", repoURL, strings.Join(allowedGitTransports, ", "))
+}
+
+// fetchGit clones a git::<repo>[//<subpath>][?ref=<ref>] module reference
+// into the module cache dir, reusing a previous clone of the same repo/ref.
+func (l *moduleLoader) fetchGit(spec string) (string, error) {
+	repoURL, subPath, ref := parseGitModuleSpec(spec)
+
+	if err := validateGitRepoURL(repoURL); err != nil {
+		return "", fmt.Errorf("rejecting git module: %w", err)
+	}
+
+	cloneDir := filepath.Join(l.cacheDir, "git", cacheKey(repoURL+"@"+ref))
+	if _, err := os.Stat(cloneDir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(cloneDir), 0755); err != nil {
+			return "", fmt.Errorf("creating module cache dir: %w", err)
+		}
+
+		args := []string{"clone", "--depth", "1"}
+		if ref != "" {
+			args = append(args, "--branch", ref)
+		}
+		args = append(args, repoURL, cloneDir)
+
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("cloning %s: %w: %s", repoURL, err, out)
+		}
+	}
+
+	return filepath.Join(cloneDir, subPath), nil
+}
+
+// parseGitModuleSpec splits a go-getter-style git module reference
+// (repo[//subpath][?ref=ref]) into its parts.
+func parseGitModuleSpec(spec string) (repoURL, subPath, ref string) {
+	if i := strings.Index(spec, "?ref="); i != -1 {
+		ref = spec[i+len("?ref="):]
+		spec = spec[:i]
+	}
+
+	// Skip the scheme separator ("://") so it isn't mistaken for the
+	// repo/subpath separator ("//").
+	searchFrom := 0
+	if i := strings.Index(spec, "://"); i != -1 {
+		searchFrom = i + len("://")
+	}
+
+	if i := strings.Index(spec[searchFrom:], "//"); i != -1 {
+		split := searchFrom + i
+		return spec[:split], spec[split+2:], ref
+	}
+
+	return spec, "", ref
+}
+
+func cacheKey(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// renderTemplateFile reads path and renders it as a Go template using data.
+func renderTemplateFile(path string, data map[string]interface{}) ([]byte, error) {
+	b, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("executing template: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// mergeSingularityConfig merges src into dst. Scalar fields in src
+// override dst when non-zero; maps (Env, RequiredSlaveAttributes) are
+// merged key by key; slices (Owners, Arguments, URIs) are appended.
+// provenance records which source last touched each field.
+func mergeSingularityConfig(dst *SingularityConfig, src SingularityConfig, provenance map[string]string, source string) {
+	set := func(field string) { provenance[field] = source }
+
+	if src.Command != "" {
+		dst.Command = src.Command
+		set("command")
+	}
+	if src.DeployID != "" {
+		dst.DeployID = src.DeployID
+		set("deploy-id")
+	}
+	if len(src.Env) > 0 {
+		if dst.Env == nil {
+			dst.Env = make(map[string]string, len(src.Env))
+		}
+		for k, v := range src.Env {
+			dst.Env[k] = v
+			set("env." + k)
+		}
+	}
+	if src.KillOldNonLongRunningTasksAfterMillis != 0 {
+		dst.KillOldNonLongRunningTasksAfterMillis = src.KillOldNonLongRunningTasksAfterMillis
+		set("kill-old-non-long-running-tasks-after-millis")
+	}
+	if src.NumRetriesOnFailure != 0 {
+		dst.NumRetriesOnFailure = src.NumRetriesOnFailure
+		set("num-retries-on-failure")
+	}
+	if len(src.Owners) > 0 {
+		dst.Owners = append(dst.Owners, src.Owners...)
+		set("owners")
+	}
+	if src.RequestType != "" {
+		dst.RequestType = src.RequestType
+		set("request-type")
+	}
+	if len(src.RequiredSlaveAttributes) > 0 {
+		if dst.RequiredSlaveAttributes == nil {
+			dst.RequiredSlaveAttributes = make(map[string]string, len(src.RequiredSlaveAttributes))
+		}
+		for k, v := range src.RequiredSlaveAttributes {
+			dst.RequiredSlaveAttributes[k] = v
+			set("required-slave-attributes." + k)
+		}
+	}
+	if src.Schedule != "" {
+		dst.Schedule = src.Schedule
+		set("schedule")
+	}
+	if src.ScheduledExpectedRuntimeMillis != 0 {
+		dst.ScheduledExpectedRuntimeMillis = src.ScheduledExpectedRuntimeMillis
+		set("scheduled-expected-runtime-millis")
+	}
+	if src.RequestID != "" {
+		dst.RequestID = src.RequestID
+		set("request-id")
+	}
+	if len(src.Arguments) > 0 {
+		dst.Arguments = append(dst.Arguments, src.Arguments...)
+		set("arguments")
+	}
+	if src.ContainerInfo.Type != "" {
+		dst.ContainerInfo = src.ContainerInfo
+		set("container-info")
+	}
+	if src.Resources.NumPorts != 0 {
+		dst.Resources.NumPorts = src.Resources.NumPorts
+		set("resources.num-ports")
+	}
+	if src.Resources.MemoryMb != 0 {
+		dst.Resources.MemoryMb = src.Resources.MemoryMb
+		set("resources.memory-mb")
+	}
+	if src.Resources.CPUs != 0 {
+		dst.Resources.CPUs = src.Resources.CPUs
+		set("resources.cpus")
+	}
+	if src.Resources.DiskMb != 0 {
+		dst.Resources.DiskMb = src.Resources.DiskMb
+		set("resources.disk-mb")
+	}
+	if len(src.URIs) > 0 {
+		dst.URIs = append(dst.URIs, src.URIs...)
+		set("uris")
+	}
+	if src.Healthcheck.URI != "" {
+		dst.Healthcheck = src.Healthcheck
+		set("healthcheck")
+	}
+	if len(src.Mounts) > 0 {
+		dst.Mounts = append(dst.Mounts, src.Mounts...)
+		set("mounts")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleLoaderMergesIncludedFragment(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "base.yml"), []byte(`
+env:
+  BASE: "true"
+request-type: SERVICE
+`), 0644))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "top.yml"), []byte(`
+modules:
+  - name: base.yml
+request-id: my-request
+`), 0644))
+
+	loader := newModuleLoader(t.TempDir())
+	cfg, err := loader.load(filepath.Join(dir, "top.yml"), ".", nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "SERVICE", cfg.RequestType)
+	assert.Equal(t, "my-request", cfg.RequestID)
+	assert.Equal(t, "true", cfg.Env["BASE"])
+}
+
+func TestModuleLoaderTopLevelOverridesModule(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "base.yml"), []byte(`
+request-type: WORKER
+`), 0644))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "top.yml"), []byte(`
+modules:
+  - name: base.yml
+request-type: SERVICE
+`), 0644))
+
+	loader := newModuleLoader(t.TempDir())
+	cfg, err := loader.load(filepath.Join(dir, "top.yml"), ".", nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "SERVICE", cfg.RequestType)
+}
+
+func TestModuleLoaderAppendsArgumentsAcrossModules(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "base.yml"), []byte(`
+arguments:
+  - "--base"
+`), 0644))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "top.yml"), []byte(`
+modules:
+  - name: base.yml
+arguments:
+  - "--top"
+`), 0644))
+
+	loader := newModuleLoader(t.TempDir())
+	cfg, err := loader.load(filepath.Join(dir, "top.yml"), ".", nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"--base", "--top"}, cfg.Arguments)
+}
+
+func TestModuleLoaderRendersModuleWithParams(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "base.yml"), []byte(`
+request-id: {{.name}}
+`), 0644))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "top.yml"), []byte(`
+modules:
+  - name: base.yml
+    with:
+      name: my-service
+`), 0644))
+
+	loader := newModuleLoader(t.TempDir())
+	cfg, err := loader.load(filepath.Join(dir, "top.yml"), ".", nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "my-service", cfg.RequestID)
+}
+
+func TestModuleLoaderDetectsCycles(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.yml"), []byte(`
+modules:
+  - name: b.yml
+`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.yml"), []byte(`
+modules:
+  - name: a.yml
+`), 0644))
+
+	loader := newModuleLoader(t.TempDir())
+	_, err := loader.load(filepath.Join(dir, "a.yml"), ".", nil, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cycle detected")
+}
+
+func TestParseGitModuleSpec(t *testing.T) {
+	repo, subPath, ref := parseGitModuleSpec("https://github.com/example/modules.git//web-service?ref=v1.0.0")
+
+	assert.Equal(t, "https://github.com/example/modules.git", repo)
+	assert.Equal(t, "web-service", subPath)
+	assert.Equal(t, "v1.0.0", ref)
+}
+
+func TestValidateGitRepoURLAllowsKnownTransports(t *testing.T) {
+	for _, repo := range []string{
+		"https://github.com/example/modules.git",
+		"git://github.com/example/modules.git",
+		"ssh://git@github.com/example/modules.git",
+	} {
+		assert.NoError(t, validateGitRepoURL(repo), repo)
+	}
+}
+
+func TestValidateGitRepoURLRejectsPseudoTransports(t *testing.T) {
+	for _, repo := range []string{
+		"ext::sh -c touch /tmp/pwned",
+		"fd::0",
+		"file:///etc/passwd",
+		"-upload-pack=touch /tmp/pwned",
+	} {
+		assert.Error(t, validateGitRepoURL(repo), repo)
+	}
+}
+
+func TestModuleLoaderRejectsDisallowedGitTransport(t *testing.T) {
+	loader := newModuleLoader(t.TempDir())
+	_, err := loader.load("git::ext::sh -c 'touch /tmp/pwned'", ".", nil, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not use an allowed transport")
+}
+
+func TestMergeSingularityConfigMergesEnvAndAppendsOwners(t *testing.T) {
+	dst := SingularityConfig{
+		Env:    map[string]string{"FOO": "bar"},
+		Owners: []string{"a@example.com"},
+	}
+	src := SingularityConfig{
+		Env:    map[string]string{"BAZ": "qux"},
+		Owners: []string{"b@example.com"},
+	}
+
+	provenance := make(map[string]string)
+	mergeSingularityConfig(&dst, src, provenance, "fragment.yml")
+
+	assert.Equal(t, "bar", dst.Env["FOO"])
+	assert.Equal(t, "qux", dst.Env["BAZ"])
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, dst.Owners)
+	assert.Equal(t, "fragment.yml", provenance["env.BAZ"])
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SingularityMount is a convenience shortcut in the config YAML: instead of
+// hand-writing SingularityVolume entries for /etc/passwd and /etc/group,
+// list the users/groups that need to resolve inside the container and
+// generatePasswdGroupFiles builds both files and the read-only volumes
+// that mount them in, the same way container runtimes auto-mount them.
+type SingularityMount struct {
+	Username string `yaml:"username"`
+	UID      int    `yaml:"uid"`
+	Group    string `yaml:"group"`
+	GID      int    `yaml:"gid"`
+	Home     string `yaml:"home"`
+	Shell    string `yaml:"shell"`
+}
+
+const (
+	defaultMountHome  = "/"
+	defaultMountShell = "/sbin/nologin"
+
+	generatedPasswdFilename = "passwd"
+	generatedGroupFilename  = "group"
+)
+
+// validateMounts checks that mounts can actually take effect: they generate
+// volumes mounted into a container, so they're meaningless without a
+// container-info block declared alongside them.
+func validateMounts(cfg SingularityConfig) error {
+	if len(cfg.Mounts) > 0 && cfg.ContainerInfo.Type == "" {
+		return fmt.Errorf("mounts are configured but container-info.type is not set - mounts are only meaningful inside a container")
+	}
+	return nil
+}
+
+// generatePasswdGroupFiles writes a passwd and a group file under outputDir,
+// built from mounts, and returns the read-only SingularityVolume entries
+// that mount them into the container at /etc/passwd and /etc/group.
+func generatePasswdGroupFiles(mounts []SingularityMount, outputDir string) ([]SingularityVolume, error) {
+	if len(mounts) == 0 {
+		return nil, nil
+	}
+
+	passwdPath := filepath.Join(outputDir, generatedPasswdFilename)
+	if err := writeFile(passwdPath, []byte(passwdFileContents(mounts))); err != nil {
+		return nil, fmt.Errorf("writing generated passwd file: %w", err)
+	}
+
+	groupPath := filepath.Join(outputDir, generatedGroupFilename)
+	if err := writeFile(groupPath, []byte(groupFileContents(mounts))); err != nil {
+		return nil, fmt.Errorf("writing generated group file: %w", err)
+	}
+
+	absPasswdPath, err := filepath.Abs(passwdPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolving path to generated passwd file: %w", err)
+	}
+	absGroupPath, err := filepath.Abs(groupPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolving path to generated group file: %w", err)
+	}
+
+	return []SingularityVolume{
+		{HostPath: absPasswdPath, ContainerPath: "/etc/passwd", Mode: "RO"},
+		{HostPath: absGroupPath, ContainerPath: "/etc/group", Mode: "RO"},
+	}, nil
+}
+
+// passwdFileContents renders mounts as the contents of an /etc/passwd file.
+func passwdFileContents(mounts []SingularityMount) string {
+	lines := make([]string, 0, len(mounts))
+	for _, m := range mounts {
+		home := m.Home
+		if home == "" {
+			home = defaultMountHome
+		}
+		shell := m.Shell
+		if shell == "" {
+			shell = defaultMountShell
+		}
+		lines = append(lines, fmt.Sprintf("%s:x:%d:%d::%s:%s", m.Username, m.UID, m.GID, home, shell))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// groupFileContents renders the distinct GIDs in mounts as the contents of
+// an /etc/group file.
+func groupFileContents(mounts []SingularityMount) string {
+	names := make(map[int]string, len(mounts))
+	gids := make([]int, 0, len(mounts))
+	for _, m := range mounts {
+		if _, seen := names[m.GID]; seen {
+			continue
+		}
+		name := m.Group
+		if name == "" {
+			name = m.Username
+		}
+		names[m.GID] = name
+		gids = append(gids, m.GID)
+	}
+	sort.Ints(gids)
+
+	lines := make([]string, 0, len(gids))
+	for _, gid := range gids {
+		lines = append(lines, fmt.Sprintf("%s:x:%d:", names[gid], gid))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// schemaFS embeds our own hand-written, partial JSON schemas covering the
+// subset of the Singularity request/deploy API we generate - not a copy of
+// Singularity's own schema - so the generator can catch obviously malformed
+// output without needing network access to the scheduler. They only require
+// the fields this tool relies on and leave additionalProperties true, so
+// they will not catch every API-shape error the scheduler itself would
+// reject.
+//
+//go:embed schema/*.json
+var schemaFS embed.FS
+
+// validateSchema validates document against the bundled schema named
+// schemaName (e.g. "request", "deploy"), returning every violation found
+// rather than stopping at the first one.
+func validateSchema(schemaName string, document []byte) error {
+	schemaBytes, err := schemaFS.ReadFile(fmt.Sprintf("schema/%s.schema.json", schemaName))
+	if err != nil {
+		return fmt.Errorf("loading %s schema: %w", schemaName, err)
+	}
+
+	schemaLoader := gojsonschema.NewBytesLoader(schemaBytes)
+	documentLoader := gojsonschema.NewBytesLoader(document)
+
+	result, err := gojsonschema.Validate(schemaLoader, documentLoader)
+	if err != nil {
+		return fmt.Errorf("validating against %s schema: %w", schemaName, err)
+	}
+
+	if result.Valid() {
+		return nil
+	}
+
+	violations := make([]string, 0, len(result.Errors()))
+	for _, re := range result.Errors() {
+		violations = append(violations, fmt.Sprintf("%s: %s", re.Field(), re.Description()))
+	}
+
+	return fmt.Errorf("%s failed schema validation:\n%s", schemaName, strings.Join(violations, "\n"))
+}
@@ -0,0 +1,149 @@
+// Package singularity provides a small HTTP client for submitting generated
+// request/deploy JSON to a running Singularity scheduler.
+//
+// See: https://github.com/HubSpot/Singularity/blob/master/Docs/reference/api.md
+package singularity
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	requestsPath      = "/api/requests"
+	pendingDeployPath = "/api/deploys/pending"
+
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultRetryWait  = time.Second
+)
+
+// Client talks to a Singularity scheduler's HTTP API.
+type Client struct {
+	// BaseURL is the scheduler's base URL, e.g. "http://singularity.example.com".
+	BaseURL string
+
+	// Token, if set, is sent as a bearer token. Username/Password are used
+	// for basic auth if Token is empty.
+	Token    string
+	Username string
+	Password string
+
+	// MaxRetries is the number of additional attempts made after a failed
+	// request before giving up.
+	MaxRetries int
+
+	// InsecureSkipVerify disables TLS certificate verification. It exists
+	// to support talking to schedulers with self-signed certificates and
+	// should not be used otherwise.
+	InsecureSkipVerify bool
+
+	httpClient *http.Client
+}
+
+// NewClient returns a Client configured to talk to baseURL.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    baseURL,
+		MaxRetries: defaultMaxRetries,
+	}
+}
+
+// APIError is returned when the scheduler rejects a submitted payload.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("singularity scheduler returned %d: %s", e.StatusCode, e.Body)
+}
+
+// SubmitRequest submits a generated request JSON document via
+// POST /api/requests.
+func (c *Client) SubmitRequest(requestJSON []byte) error {
+	return c.post(requestsPath, requestJSON)
+}
+
+// SubmitDeploy submits a generated deploy JSON document via
+// POST /api/deploys/pending.
+func (c *Client) SubmitDeploy(deployJSON []byte) error {
+	return c.post(pendingDeployPath, deployJSON)
+}
+
+func (c *Client) client() *http.Client {
+	if c.httpClient != nil {
+		return c.httpClient
+	}
+
+	c.httpClient = &http.Client{
+		Timeout: defaultTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify},
+		},
+	}
+
+	return c.httpClient
+}
+
+func (c *Client) post(path string, body []byte) error {
+	var lastErr error
+
+	for attempt := 0; attempt <= c.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(defaultRetryWait * time.Duration(attempt))
+		}
+
+		err := c.doPost(path, body)
+		if err == nil {
+			return nil
+		}
+
+		// Don't retry requests the scheduler has actively rejected - a
+		// retry won't make a 4xx/5xx response from the scheduler itself
+		// succeed.
+		if _, ok := err.(*APIError); ok {
+			return err
+		}
+
+		lastErr = err
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", c.MaxRetries+1, lastErr)
+}
+
+func (c *Client) doPost(path string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building request for %s: %w", path, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("submitting to %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response from %s: %w", path, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package singularity
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitRequestSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/requests", r.URL.Path)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":"test"}`, string(body))
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.Token = "test-token"
+
+	err := c.SubmitRequest([]byte(`{"id":"test"}`))
+	assert.NoError(t, err)
+}
+
+func TestSubmitDeployRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/deploys/pending", r.URL.Path)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"invalid deploy"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+
+	err := c.SubmitDeploy([]byte(`{"deploy":{}}`))
+	assert.Error(t, err)
+
+	apiErr, ok := err.(*APIError)
+	if assert.True(t, ok, "expected an *APIError") {
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Contains(t, apiErr.Body, "invalid deploy")
+	}
+}
+
+func TestSubmitUsesBasicAuthWhenNoToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "deployer", user)
+		assert.Equal(t, "secret", pass)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.Username = "deployer"
+	c.Password = "secret"
+
+	err := c.SubmitRequest([]byte(`{}`))
+	assert.NoError(t, err)
+}
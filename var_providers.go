@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/multierr"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// VarProvider supplies key/value template variables that are merged into
+// SingularityConfigData before the config template is rendered.
+type VarProvider interface {
+	// Name identifies the provider, used in error messages.
+	Name() string
+	// Vars returns the key/value pairs this provider contributes.
+	Vars() (map[string]string, error)
+}
+
+// cliVarProvider implements VarProvider for repeated -var key=value flags.
+type cliVarProvider struct {
+	vars stringmap
+}
+
+func (p cliVarProvider) Name() string {
+	return "-var"
+}
+
+func (p cliVarProvider) Vars() (map[string]string, error) {
+	return map[string]string(p.vars), nil
+}
+
+// fileVarProvider implements VarProvider for a -var-file flag pointing at a
+// bulk YAML or JSON file of key/value pairs.
+type fileVarProvider struct {
+	path string
+}
+
+func (p fileVarProvider) Name() string {
+	return fmt.Sprintf("-var-file %s", p.path)
+}
+
+func (p fileVarProvider) Vars() (map[string]string, error) {
+	b, err := readFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	if err := yaml.Unmarshal(b, &vars); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", p.path, err)
+	}
+
+	return vars, nil
+}
+
+// envVarProvider implements VarProvider for a -var-env PREFIX_ flag, which
+// imports every PREFIX_* environment variable with the prefix stripped.
+type envVarProvider struct {
+	prefix string
+}
+
+func (p envVarProvider) Name() string {
+	return fmt.Sprintf("-var-env %s", p.prefix)
+}
+
+func (p envVarProvider) Vars() (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, kv := range os.Environ() {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(k, p.prefix) {
+			continue
+		}
+		vars[strings.TrimPrefix(k, p.prefix)] = v
+	}
+	return vars, nil
+}
+
+// secretFileVarProvider implements VarProvider for a -var-secret-file flag
+// pointing at a local Kubernetes Secret manifest.
+type secretFileVarProvider struct {
+	path string
+}
+
+func (p secretFileVarProvider) Name() string {
+	return fmt.Sprintf("-var-secret-file %s", p.path)
+}
+
+func (p secretFileVarProvider) Vars() (map[string]string, error) {
+	b, err := readFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest kubeSecretManifest
+	if err := yaml.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing secret manifest %s: %w", p.path, err)
+	}
+
+	return decodeSecretData(manifest.Data)
+}
+
+// secretVarProvider implements VarProvider for a -var-secret flag, which
+// fetches a live Secret from the Kubernetes API (in-cluster, or via the
+// current kubeconfig context).
+type secretVarProvider struct {
+	namespace string
+	name      string
+}
+
+func (p secretVarProvider) Name() string {
+	return fmt.Sprintf("-var-secret %s", p.name)
+}
+
+func (p secretVarProvider) Vars() (map[string]string, error) {
+	client, err := newKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("building kubernetes client: %w", err)
+	}
+
+	return client.GetSecret(p.namespace, p.name)
+}
+
+// varProviders returns the configured VarProviders in deterministic
+// precedence order. Providers later in the slice override earlier ones
+// when keys collide; -var always wins since it is the most specific,
+// explicitly-typed-by-hand source.
+func varProviders() []VarProvider {
+	providers := make([]VarProvider, 0, len(varEnvPrefixes)+len(varSecretFiles)+len(varSecrets)+len(varFiles)+1)
+
+	for _, prefix := range varEnvPrefixes {
+		providers = append(providers, envVarProvider{prefix: prefix})
+	}
+	for _, path := range varSecretFiles {
+		providers = append(providers, secretFileVarProvider{path: path})
+	}
+	for _, name := range varSecrets {
+		providers = append(providers, secretVarProvider{namespace: varSecretNamespace, name: name})
+	}
+	for _, path := range varFiles {
+		providers = append(providers, fileVarProvider{path: path})
+	}
+	providers = append(providers, cliVarProvider{vars: commandLineVars})
+
+	return providers
+}
+
+// mergeVarProviders runs every provider and merges their variables into a
+// single SingularityConfigData, in the order given. Providers are
+// independent - an error from one does not stop the others from running,
+// and every error is collected and returned together.
+func mergeVarProviders(providers []VarProvider) (SingularityConfigData, error) {
+	data := make(SingularityConfigData)
+	var errs error
+
+	for _, p := range providers {
+		vars, err := p.Vars()
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			continue
+		}
+		for k, v := range vars {
+			data[k] = v
+		}
+	}
+
+	return data, errs
+}
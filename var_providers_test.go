@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCLIVarProvider(t *testing.T) {
+	p := cliVarProvider{vars: stringmap{"FOO": "bar"}}
+
+	vars, err := p.Vars()
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", vars["FOO"])
+}
+
+func TestFileVarProviderYAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vars.yml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("FOO: bar\nBAZ: qux\n"), 0644))
+
+	vars, err := (fileVarProvider{path: path}).Vars()
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", vars["FOO"])
+	assert.Equal(t, "qux", vars["BAZ"])
+}
+
+func TestFileVarProviderJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vars.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"FOO":"bar"}`), 0644))
+
+	vars, err := (fileVarProvider{path: path}).Vars()
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", vars["FOO"])
+}
+
+func TestFileVarProviderMissingFile(t *testing.T) {
+	_, err := (fileVarProvider{path: "/does/not/exist.yml"}).Vars()
+	assert.Error(t, err)
+}
+
+func TestEnvVarProvider(t *testing.T) {
+	os.Setenv("SCG_FOO", "bar")
+	defer os.Unsetenv("SCG_FOO")
+
+	vars, err := (envVarProvider{prefix: "SCG_"}).Vars()
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", vars["FOO"])
+}
+
+func TestSecretFileVarProvider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.yml")
+	manifest := "data:\n  password: " + base64.StdEncoding.EncodeToString([]byte("hunter2")) + "\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(manifest), 0644))
+
+	vars, err := (secretFileVarProvider{path: path}).Vars()
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", vars["password"])
+}
+
+func TestMergeVarProvidersPrecedence(t *testing.T) {
+	low := cliVarProvider{vars: stringmap{"FOO": "low"}}
+	high := cliVarProvider{vars: stringmap{"FOO": "high"}}
+
+	data, err := mergeVarProviders([]VarProvider{low, high})
+	assert.NoError(t, err)
+	assert.Equal(t, "high", data["FOO"])
+}
+
+func TestMergeVarProvidersCollectsErrors(t *testing.T) {
+	ok := cliVarProvider{vars: stringmap{"FOO": "bar"}}
+	bad := fileVarProvider{path: "/does/not/exist.yml"}
+
+	data, err := mergeVarProviders([]VarProvider{ok, bad})
+	assert.Error(t, err)
+	assert.Equal(t, "bar", data["FOO"])
+}